@@ -0,0 +1,38 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/falcosecurity/falcoctl/cmd/artifact/verify"
+	"github.com/falcosecurity/falcoctl/pkg/options"
+)
+
+// NewArtifactCmd returns the artifact command.
+func NewArtifactCmd(ctx context.Context, opt *options.CommonOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "artifact",
+		DisableFlagsInUseLine: true,
+		Short:                 "Handle falcoctl artifacts",
+		Long:                  "Handle falcoctl artifacts",
+	}
+
+	cmd.AddCommand(verify.NewVerifyCmd(ctx, opt))
+
+	return cmd
+}
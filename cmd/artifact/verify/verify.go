@@ -0,0 +1,89 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package verify implements the `falcoctl artifact verify` command.
+package verify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/cobra"
+
+	"github.com/falcosecurity/falcoctl/pkg/options"
+	"github.com/falcosecurity/falcoctl/pkg/policy"
+)
+
+type artifactVerifyOptions struct {
+	*options.CommonOptions
+	policyPath string
+}
+
+// NewVerifyCmd returns the `falcoctl artifact verify` command.
+func NewVerifyCmd(ctx context.Context, opt *options.CommonOptions) *cobra.Command {
+	o := artifactVerifyOptions{
+		CommonOptions: opt,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "verify [ref1 [ref2 ...]] [flags]",
+		DisableFlagsInUseLine: true,
+		Short:                 "Verify that artifacts are signed by a trusted signer according to a policy",
+		Long: "Verify that one or more OCI artifacts are signed by a signer accepted by a policy file " +
+			"before they are pulled and extracted. The policy describes, per registry, which keyless " +
+			"issuer/subject pairs (and required certificate extensions) or which public keys are trusted.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.RunVerify(ctx, args)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.policyPath, "policy", "", "path to a YAML or JSON artifact verification policy file")
+	if err := cmd.MarkFlagRequired("policy"); err != nil {
+		o.Printer.CheckErr(err)
+	}
+
+	return cmd
+}
+
+// RunVerify evaluates the configured policy against each of the given
+// artifact references, aborting on the first one that is not allowed.
+func (o *artifactVerifyOptions) RunVerify(ctx context.Context, refs []string) error {
+	pol, err := policy.Load(o.policyPath)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		parsed, err := name.ParseReference(ref)
+		if err != nil {
+			return fmt.Errorf("parsing reference %q: %w", ref, err)
+		}
+
+		ap, ok := pol.For(parsed.Context().RegistryStr())
+		if !ok {
+			return fmt.Errorf("no policy configured for registry %q", parsed.Context().RegistryStr())
+		}
+
+		report, err := policy.Evaluate(ctx, ap, ref)
+		if err != nil {
+			return err
+		}
+
+		o.Printer.Success.Printfln("artifact %q is allowed by policy", report.Image)
+	}
+
+	return nil
+}
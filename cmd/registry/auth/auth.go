@@ -23,11 +23,12 @@ import (
 	"github.com/falcosecurity/falcoctl/cmd/registry/auth/basic"
 	"github.com/falcosecurity/falcoctl/cmd/registry/auth/gcp"
 	"github.com/falcosecurity/falcoctl/cmd/registry/auth/oauth"
-	commonoptions "github.com/falcosecurity/falcoctl/pkg/options"
+	"github.com/falcosecurity/falcoctl/cmd/registry/auth/oidc"
+	"github.com/falcosecurity/falcoctl/pkg/options"
 )
 
 // NewAuthCmd returns the registry command.
-func NewAuthCmd(ctx context.Context, opt *commonoptions.Common) *cobra.Command {
+func NewAuthCmd(ctx context.Context, opt *options.CommonOptions) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:                   "auth",
 		DisableFlagsInUseLine: true,
@@ -38,6 +39,7 @@ func NewAuthCmd(ctx context.Context, opt *commonoptions.Common) *cobra.Command {
 	cmd.AddCommand(basic.NewBasicCmd(ctx, opt))
 	cmd.AddCommand(oauth.NewOauthCmd(ctx, opt))
 	cmd.AddCommand(gcp.NewGcpCmd(ctx, opt))
+	cmd.AddCommand(oidc.NewOidcCmd(ctx, opt))
 
 	return cmd
 }
@@ -0,0 +1,223 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oidc implements the `falcoctl registry auth oidc` command.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"oras.land/oras-go/pkg/auth/docker"
+
+	"github.com/falcosecurity/falcoctl/pkg/options"
+)
+
+const (
+	// rfc8693TokenExchangeGrantType is the RFC 8693 grant_type value for a
+	// token exchange.
+	rfc8693TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	// rfc8693AccessTokenType is the requested_token_type for an OAuth2 bearer
+	// access token, per RFC 8693 section 3.
+	rfc8693AccessTokenType = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+type oidcOptions struct {
+	*options.CommonOptions
+	server       string
+	tokenURL     string
+	ambientToken string
+	audience     string
+	scope        string
+}
+
+// NewOidcCmd returns the registry auth oidc command.
+func NewOidcCmd(ctx context.Context, opt *options.CommonOptions) *cobra.Command {
+	o := oidcOptions{
+		CommonOptions: opt,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "oidc [server] [flags]",
+		DisableFlagsInUseLine: true,
+		Short:                 "Exchange an ambient OIDC token for registry credentials",
+		Long: "Exchange an ambient OIDC identity token for a registry bearer token via an RFC 8693 token " +
+			"exchange, and store it using the same credential store as the other auth subcommands. The " +
+			"identity token is taken from --oidc-token-path (point this at a Kubernetes projected service " +
+			"account token to use one), falling back to the GitHub Actions " +
+			"ACTIONS_ID_TOKEN_REQUEST_URL/TOKEN environment.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			o.server = args[0]
+			return o.RunOidc(ctx)
+		},
+	}
+
+	cmd.Flags().StringVar(&o.tokenURL, "token-url", "", "OAuth2 token exchange endpoint (defaults to https://<server>/token)")
+	cmd.Flags().StringVar(&o.ambientToken, "oidc-token-path", "",
+		"path to a file containing the ambient OIDC identity token, e.g. a Kubernetes projected service account token")
+	cmd.Flags().StringVar(&o.audience, "audience", "", "audience to request for the ambient OIDC identity token")
+	cmd.Flags().StringVar(&o.scope, "scope", "", "scope to request for the exchanged registry token")
+
+	return cmd
+}
+
+// RunOidc fetches an ambient OIDC identity token, exchanges it for a
+// registry bearer token, and persists it to the credential store.
+func (o *oidcOptions) RunOidc(ctx context.Context) error {
+	idToken, err := ambientOIDCToken(ctx, o.ambientToken, o.audience)
+	if err != nil {
+		return fmt.Errorf("obtaining ambient OIDC token: %w", err)
+	}
+
+	tokenURL := o.tokenURL
+	if tokenURL == "" {
+		tokenURL = fmt.Sprintf("https://%s/token", o.server)
+	}
+
+	registryToken, err := exchangeToken(ctx, tokenURL, idToken, o.scope)
+	if err != nil {
+		return fmt.Errorf("exchanging OIDC token for a registry token: %w", err)
+	}
+
+	cli, err := docker.NewClient(o.ConfigFile())
+	if err != nil {
+		return fmt.Errorf("creating credential store client: %w", err)
+	}
+
+	if err := cli.LoginWithOpts(
+		docker.WithLoginContext(ctx),
+		docker.WithLoginHostname(o.server),
+		docker.WithLoginUsername("<token>"),
+		docker.WithLoginSecret(registryToken),
+	); err != nil {
+		return fmt.Errorf("storing registry token: %w", err)
+	}
+
+	o.Printer.Success.Printfln("logged in via OIDC to %s", o.server)
+	return nil
+}
+
+// ambientOIDCToken returns the OIDC identity token to exchange, preferring an
+// explicit file path (this is how a Kubernetes projected service account
+// token is consumed: the workload mounts it at a path of its own choosing,
+// with its own audience, via a "serviceAccountToken" projected volume),
+// then the GitHub Actions runtime. There is no implicit Kubernetes
+// fallback: the default, auto-mounted service account token is scoped to
+// the API server's audience, so it cannot stand in for one requested via
+// --audience.
+func ambientOIDCToken(ctx context.Context, path, audience string) (string, error) {
+	if path != "" {
+		tok, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading %q: %w", path, err)
+		}
+		return strings.TrimSpace(string(tok)), nil
+	}
+
+	if reqURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"); reqURL != "" {
+		return githubActionsOIDCToken(ctx, reqURL, os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN"), audience)
+	}
+
+	return "", fmt.Errorf("no ambient OIDC token found: set --oidc-token-path, or run inside GitHub Actions")
+}
+
+func githubActionsOIDCToken(ctx context.Context, requestURL, requestToken, audience string) (string, error) {
+	if requestToken == "" {
+		return "", fmt.Errorf("ACTIONS_ID_TOKEN_REQUEST_URL is set but ACTIONS_ID_TOKEN_REQUEST_TOKEN is not")
+	}
+
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+	if audience != "" {
+		q := u.Query()
+		q.Set("audience", audience)
+		u.RawQuery = q.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting GitHub Actions OIDC token: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding GitHub Actions OIDC token response: %w", err)
+	}
+	if body.Value == "" {
+		return "", fmt.Errorf("GitHub Actions OIDC token response did not contain a token")
+	}
+	return body.Value, nil
+}
+
+// exchangeToken performs an RFC 8693 OAuth2 token exchange, trading idToken
+// for a registry-scoped bearer token.
+func exchangeToken(ctx context.Context, tokenURL, idToken, scope string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", rfc8693TokenExchangeGrantType)
+	form.Set("subject_token", idToken)
+	form.Set("subject_token_type", "urn:ietf:params:oauth:token-type:id_token")
+	form.Set("requested_token_type", rfc8693AccessTokenType)
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token exchange failed: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding token exchange response: %w", err)
+	}
+	if body.AccessToken == "" {
+		return "", fmt.Errorf("token exchange response did not contain an access_token")
+	}
+	return body.AccessToken, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustedroot
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/spf13/cobra"
+
+	"github.com/falcosecurity/falcoctl/internal/cosign"
+	"github.com/falcosecurity/falcoctl/pkg/options"
+)
+
+type createOptions struct {
+	*options.CommonOptions
+	fulcioCertChainPath string
+	ctlogKeyPath        string
+	rekorKeyPath        string
+	tsaCertChainPath    string
+	validFrom           string
+	outPath             string
+}
+
+// NewCreateCmd returns the `falcoctl trusted-root create` command.
+func NewCreateCmd(ctx context.Context, opt *options.CommonOptions) *cobra.Command {
+	o := createOptions{
+		CommonOptions: opt,
+	}
+
+	cmd := &cobra.Command{
+		Use:                   "create",
+		DisableFlagsInUseLine: true,
+		Short:                 "Assemble a trusted_root.json bundle from local PEM inputs",
+		Long: "Assemble a Sigstore trusted_root.json bundle from locally supplied Fulcio/TSA certificate chains " +
+			"and CT log/Rekor public keys, so that air-gapped falcoctl deployments can pin and rotate their own " +
+			"trust roots with explicit validity windows.",
+		Args: cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.RunCreate()
+		},
+	}
+
+	cmd.Flags().StringVar(&o.fulcioCertChainPath, "fulcio-cert-chain", "", "path to a PEM Fulcio certificate chain (leaf, intermediates, root)")
+	cmd.Flags().StringVar(&o.ctlogKeyPath, "ctlog-public-key", "", "path to a PEM CT log public key")
+	cmd.Flags().StringVar(&o.rekorKeyPath, "rekor-public-key", "", "path to a PEM Rekor public key")
+	cmd.Flags().StringVar(&o.tsaCertChainPath, "tsa-cert-chain", "", "path to a PEM TSA certificate chain (leaf, intermediates, root)")
+	cmd.Flags().StringVar(&o.validFrom, "valid-from", "", "RFC3339 timestamp the supplied trust material is valid from (default: now)")
+	cmd.Flags().StringVar(&o.outPath, "output", "trusted_root.json", "path to write the assembled trusted_root.json to")
+
+	return cmd
+}
+
+// RunCreate assembles a trusted_root.json out of the configured inputs and
+// writes it to outPath.
+func (o *createOptions) RunCreate() error {
+	validFrom := time.Now()
+	if o.validFrom != "" {
+		var err error
+		validFrom, err = time.Parse(time.RFC3339, o.validFrom)
+		if err != nil {
+			return fmt.Errorf("parsing --valid-from: %w", err)
+		}
+	}
+
+	var fulcio, tsa []cosign.TrustedCertChainInput
+	var ctlogs, tlogs []cosign.TrustedKeyInput
+
+	if o.fulcioCertChainPath != "" {
+		certs, err := loadCertChain(o.fulcioCertChainPath)
+		if err != nil {
+			return fmt.Errorf("loading Fulcio certificate chain: %w", err)
+		}
+		fulcio = append(fulcio, cosign.TrustedCertChainInput{Certs: certs, ValidFrom: validFrom})
+	}
+	if o.tsaCertChainPath != "" {
+		certs, err := loadCertChain(o.tsaCertChainPath)
+		if err != nil {
+			return fmt.Errorf("loading TSA certificate chain: %w", err)
+		}
+		tsa = append(tsa, cosign.TrustedCertChainInput{Certs: certs, ValidFrom: validFrom})
+	}
+	if o.ctlogKeyPath != "" {
+		der, err := loadPublicKeyDER(o.ctlogKeyPath)
+		if err != nil {
+			return fmt.Errorf("loading CT log public key: %w", err)
+		}
+		ctlogs = append(ctlogs, cosign.TrustedKeyInput{PublicKeyDER: der, ValidFrom: validFrom})
+	}
+	if o.rekorKeyPath != "" {
+		der, err := loadPublicKeyDER(o.rekorKeyPath)
+		if err != nil {
+			return fmt.Errorf("loading Rekor public key: %w", err)
+		}
+		tlogs = append(tlogs, cosign.TrustedKeyInput{PublicKeyDER: der, ValidFrom: validFrom})
+	}
+
+	if len(fulcio) == 0 && len(tsa) == 0 && len(ctlogs) == 0 && len(tlogs) == 0 {
+		return fmt.Errorf("at least one of --fulcio-cert-chain, --tsa-cert-chain, --ctlog-public-key or --rekor-public-key is required")
+	}
+
+	data, err := cosign.AssembleTrustedRoot(fulcio, tsa, ctlogs, tlogs)
+	if err != nil {
+		return fmt.Errorf("assembling trusted root: %w", err)
+	}
+
+	if err := os.WriteFile(o.outPath, data, 0o600); err != nil {
+		return fmt.Errorf("writing %q: %w", o.outPath, err)
+	}
+
+	o.Printer.Success.Printfln("wrote trusted root bundle to %q", o.outPath)
+	return nil
+}
+
+func loadCertChain(path string) ([]*x509.Certificate, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return cryptoutils.LoadCertificatesFromPEM(bytes.NewReader(pem))
+}
+
+func loadPublicKeyDER(path string) ([]byte, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := cryptoutils.UnmarshalPEMToPublicKey(pem)
+	if err != nil {
+		return nil, err
+	}
+	return x509.MarshalPKIXPublicKey(pub)
+}
@@ -0,0 +1,312 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sigstoreBundleJSON is the subset of the Sigstore bundle format
+// (application/vnd.dev.sigstore.bundle+json, see
+// https://github.com/sigstore/protobuf-specs Bundle message) that falcoctl
+// needs to verify a signature without any registry or TSA round-trips: the
+// signing certificate, the signature itself, the Rekor inclusion proof, and
+// an optional embedded RFC3161 timestamp.
+type sigstoreBundleJSON struct {
+	MediaType            string `json:"mediaType"`
+	VerificationMaterial struct {
+		Certificate struct {
+			RawBytes string `json:"rawBytes"`
+		} `json:"certificate"`
+		TlogEntries               []rawTlogEntryJSON `json:"tlogEntries"`
+		TimestampVerificationData struct {
+			Rfc3161Timestamps []struct {
+				SignedTimestamp string `json:"signedTimestamp"`
+			} `json:"rfc3161Timestamps"`
+		} `json:"timestampVerificationData"`
+	} `json:"verificationMaterial"`
+	MessageSignature struct {
+		MessageDigest struct {
+			Algorithm string `json:"algorithm"`
+			Digest    string `json:"digest"`
+		} `json:"messageDigest"`
+		Signature string `json:"signature"`
+	} `json:"messageSignature"`
+}
+
+// rawTlogEntryJSON is the JSON shape of one entry in
+// verificationMaterial.tlogEntries, carrying both the Rekor entry identity
+// (logIndex, logId, integratedTime, canonicalizedBody) and the Merkle
+// inclusion proof that lets it be verified without contacting Rekor.
+type rawTlogEntryJSON struct {
+	LogIndex string `json:"logIndex"`
+	LogID    struct {
+		KeyID string `json:"keyId"`
+	} `json:"logId"`
+	IntegratedTime    string `json:"integratedTime"`
+	CanonicalizedBody string `json:"canonicalizedBody"`
+	InclusionProof    struct {
+		LogIndex   string   `json:"logIndex"`
+		RootHash   string   `json:"rootHash"`
+		TreeSize   string   `json:"treeSize"`
+		Hashes     []string `json:"hashes"`
+		Checkpoint struct {
+			Envelope string `json:"envelope"`
+		} `json:"checkpoint"`
+	} `json:"inclusionProof"`
+}
+
+// InclusionProof is a Rekor Merkle tree inclusion proof, as embedded in a
+// Sigstore bundle's tlogEntries[].inclusionProof.
+type InclusionProof struct {
+	LogIndex   int64
+	RootHash   []byte
+	TreeSize   int64
+	Hashes     [][]byte
+	Checkpoint string
+}
+
+// TlogEntry is one Rekor transparency log entry embedded in a Sigstore
+// bundle, together with the inclusion proof that lets it be verified offline.
+type TlogEntry struct {
+	LogIndex          int64
+	LogID             string
+	IntegratedTime    time.Time
+	CanonicalizedBody []byte
+	InclusionProof    *InclusionProof
+}
+
+// SigstoreBundle is a parsed .sigstore/.sigstore.json bundle, with every
+// field decoded and ready to use.
+type SigstoreBundle struct {
+	Cert             *x509.Certificate
+	Signature        []byte
+	MessageDigestAlg string
+	MessageDigestHex string
+	RFC3161Timestamp []byte
+	TlogEntries      []TlogEntry
+}
+
+// IntegratedTime returns the integrated time of the bundle's first Rekor
+// transparency log entry, for selecting trusted_root.json material that was
+// valid when the signature was actually made rather than at verification
+// time. ok is false when the bundle carries no tlog entry.
+func (b *SigstoreBundle) IntegratedTime() (t time.Time, ok bool) {
+	if len(b.TlogEntries) == 0 {
+		return time.Time{}, false
+	}
+	return b.TlogEntries[0].IntegratedTime, true
+}
+
+// LoadSigstoreBundle parses a .sigstore/.sigstore.json bundle file.
+func LoadSigstoreBundle(path string) (*SigstoreBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading sigstore bundle %q: %w", path, err)
+	}
+
+	var raw sigstoreBundleJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing sigstore bundle %q: %w", path, err)
+	}
+
+	certDER, err := base64.StdEncoding.DecodeString(raw.VerificationMaterial.Certificate.RawBytes)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signing certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("parsing signing certificate: %w", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(raw.MessageSignature.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+
+	digest, err := hex.DecodeString(raw.MessageSignature.MessageDigest.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("decoding message digest: %w", err)
+	}
+
+	b := &SigstoreBundle{
+		Cert:             cert,
+		Signature:        sig,
+		MessageDigestAlg: raw.MessageSignature.MessageDigest.Algorithm,
+		MessageDigestHex: hex.EncodeToString(digest),
+	}
+
+	if len(raw.VerificationMaterial.TimestampVerificationData.Rfc3161Timestamps) > 0 {
+		ts, err := base64.StdEncoding.DecodeString(
+			raw.VerificationMaterial.TimestampVerificationData.Rfc3161Timestamps[0].SignedTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("decoding embedded RFC3161 timestamp: %w", err)
+		}
+		b.RFC3161Timestamp = ts
+	}
+
+	for i, rawEntry := range raw.VerificationMaterial.TlogEntries {
+		entry, err := decodeTlogEntry(rawEntry)
+		if err != nil {
+			return nil, fmt.Errorf("decoding tlog entry %d: %w", i, err)
+		}
+		b.TlogEntries = append(b.TlogEntries, entry)
+	}
+
+	return b, nil
+}
+
+func decodeTlogEntry(raw rawTlogEntryJSON) (TlogEntry, error) {
+	logIndex, err := strconv.ParseInt(raw.LogIndex, 10, 64)
+	if err != nil {
+		return TlogEntry{}, fmt.Errorf("parsing logIndex: %w", err)
+	}
+	integratedTimeUnix, err := strconv.ParseInt(raw.IntegratedTime, 10, 64)
+	if err != nil {
+		return TlogEntry{}, fmt.Errorf("parsing integratedTime: %w", err)
+	}
+	logIDBytes, err := base64.StdEncoding.DecodeString(raw.LogID.KeyID)
+	if err != nil {
+		return TlogEntry{}, fmt.Errorf("decoding logId: %w", err)
+	}
+	body, err := base64.StdEncoding.DecodeString(raw.CanonicalizedBody)
+	if err != nil {
+		return TlogEntry{}, fmt.Errorf("decoding canonicalizedBody: %w", err)
+	}
+
+	entry := TlogEntry{
+		LogIndex:          logIndex,
+		LogID:             hex.EncodeToString(logIDBytes),
+		IntegratedTime:    time.Unix(integratedTimeUnix, 0),
+		CanonicalizedBody: body,
+	}
+
+	proofIndex, err := strconv.ParseInt(raw.InclusionProof.LogIndex, 10, 64)
+	if err != nil {
+		return TlogEntry{}, fmt.Errorf("parsing inclusionProof.logIndex: %w", err)
+	}
+	treeSize, err := strconv.ParseInt(raw.InclusionProof.TreeSize, 10, 64)
+	if err != nil {
+		return TlogEntry{}, fmt.Errorf("parsing inclusionProof.treeSize: %w", err)
+	}
+	rootHash, err := hex.DecodeString(raw.InclusionProof.RootHash)
+	if err != nil {
+		return TlogEntry{}, fmt.Errorf("decoding inclusionProof.rootHash: %w", err)
+	}
+	hashes := make([][]byte, 0, len(raw.InclusionProof.Hashes))
+	for _, h := range raw.InclusionProof.Hashes {
+		decoded, err := hex.DecodeString(h)
+		if err != nil {
+			return TlogEntry{}, fmt.Errorf("decoding inclusionProof hash: %w", err)
+		}
+		hashes = append(hashes, decoded)
+	}
+
+	entry.InclusionProof = &InclusionProof{
+		LogIndex:   proofIndex,
+		RootHash:   rootHash,
+		TreeSize:   treeSize,
+		Hashes:     hashes,
+		Checkpoint: raw.InclusionProof.Checkpoint.Envelope,
+	}
+
+	return entry, nil
+}
+
+// rfc6962LeafHashPrefix and rfc6962NodeHashPrefix are the domain-separation
+// prefixes RFC 6962 (and the Rekor/Trillian Merkle tree it's based on) use to
+// stop a leaf hash from ever colliding with an internal node hash.
+const (
+	rfc6962LeafHashPrefix = 0x00
+	rfc6962NodeHashPrefix = 0x01
+)
+
+// VerifyInclusion checks that e's canonicalized body is included, at the
+// claimed log index, in the Merkle tree described by e's inclusion proof,
+// per the RFC 6962 audit path algorithm that Rekor's transparency log uses.
+// It does not check the checkpoint signature; callers must additionally
+// authenticate the proof's root hash against a trusted Rekor public key.
+func (e *TlogEntry) VerifyInclusion() error {
+	if e.InclusionProof == nil {
+		return fmt.Errorf("tlog entry has no inclusion proof")
+	}
+	p := e.InclusionProof
+
+	leafHash := hashLeaf(e.CanonicalizedBody)
+	computed, err := rootFromInclusionProof(p.LogIndex, p.TreeSize, leafHash, p.Hashes)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(computed, p.RootHash) {
+		return fmt.Errorf("computed Merkle root does not match the root hash in the inclusion proof")
+	}
+	return nil
+}
+
+func hashLeaf(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{rfc6962LeafHashPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hashChildren(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{rfc6962NodeHashPrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// rootFromInclusionProof recomputes the Merkle tree root hash from a leaf at
+// index within a tree of the given size and its audit path, following the
+// RFC 6962 section 2.1.1 algorithm (the same one Certificate Transparency
+// and Rekor use for their append-only logs).
+func rootFromInclusionProof(index, size int64, leafHash []byte, proof [][]byte) ([]byte, error) {
+	if index < 0 || size <= 0 || index >= size {
+		return nil, fmt.Errorf("invalid inclusion proof: index %d out of range for tree size %d", index, size)
+	}
+
+	fn, sn := index, size-1
+	node := leafHash
+	for len(proof) > 0 {
+		next, proof2 := proof[0], proof[1:]
+		if fn&1 == 1 || fn == sn {
+			node = hashChildren(next, node)
+			for sn > 0 && fn&1 == 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			node = hashChildren(node, next)
+		}
+		fn >>= 1
+		sn >>= 1
+		proof = proof2
+	}
+	if sn != 0 {
+		return nil, fmt.Errorf("invalid inclusion proof: did not consume the full audit path")
+	}
+	return node, nil
+}
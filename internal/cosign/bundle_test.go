@@ -0,0 +1,121 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestTlogEntryVerifyInclusion builds a two-leaf Merkle tree by hand and
+// checks that the leaf-0 inclusion proof verifies against its root, and
+// that tampering with either the leaf or the proof is caught.
+func TestTlogEntryVerifyInclusion(t *testing.T) {
+	leaf0 := []byte("entry-0")
+	leaf1 := []byte("entry-1")
+	sibling := hashLeaf(leaf1)
+	root := hashChildren(hashLeaf(leaf0), sibling)
+
+	entry := TlogEntry{
+		CanonicalizedBody: leaf0,
+		InclusionProof: &InclusionProof{
+			LogIndex: 0,
+			TreeSize: 2,
+			RootHash: root,
+			Hashes:   [][]byte{sibling},
+		},
+	}
+	require.NoError(t, entry.VerifyInclusion())
+
+	tampered := entry
+	tampered.CanonicalizedBody = []byte("not-the-signed-entry")
+	require.Error(t, tampered.VerifyInclusion())
+
+	tamperedProof := *entry.InclusionProof
+	tamperedProof.Hashes = [][]byte{hashLeaf([]byte("not-the-sibling"))}
+	tampered = entry
+	tampered.InclusionProof = &tamperedProof
+	require.Error(t, tampered.VerifyInclusion())
+}
+
+// TestDecodeTlogEntryFromBundleJSON builds a minimal .sigstore.json-shaped
+// document with a single, trivially-included tlog entry (a one-leaf tree, so
+// the root hash is the leaf hash itself) and checks that the bundle JSON
+// shape and decodeTlogEntry, the tlog decoding LoadSigstoreBundle relies on,
+// turn it into a TlogEntry whose inclusion proof verifies.
+func TestDecodeTlogEntryFromBundleJSON(t *testing.T) {
+	body := []byte(`{"kind":"hashedrekord"}`)
+	root := hashLeaf(body)
+
+	raw := map[string]interface{}{
+		"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.1",
+		"verificationMaterial": map[string]interface{}{
+			"certificate": map[string]string{
+				"rawBytes": base64.StdEncoding.EncodeToString([]byte("not-a-real-cert")),
+			},
+			"tlogEntries": []map[string]interface{}{
+				{
+					"logIndex":          "42",
+					"logId":             map[string]string{"keyId": base64.StdEncoding.EncodeToString([]byte("rekor-key-id"))},
+					"integratedTime":    "1700000000",
+					"canonicalizedBody": base64.StdEncoding.EncodeToString(body),
+					"inclusionProof": map[string]interface{}{
+						"logIndex": "0",
+						"rootHash": hex.EncodeToString(root),
+						"treeSize": "1",
+						"hashes":   []string{},
+						"checkpoint": map[string]string{
+							"envelope": "",
+						},
+					},
+				},
+			},
+		},
+		"messageSignature": map[string]interface{}{
+			"messageDigest": map[string]string{
+				"algorithm": "SHA2_256",
+				"digest":    hex.EncodeToString([]byte{1, 2, 3, 4}),
+			},
+			"signature": base64.StdEncoding.EncodeToString([]byte("not-a-real-signature")),
+		},
+	}
+
+	// x509.ParseCertificate on "not-a-real-cert" fails, so sidestep the
+	// certificate field for this parsing-focused test by asserting the
+	// tlog entry decoded correctly before LoadSigstoreBundle gets to it.
+	data, err := json.Marshal(raw)
+	require.NoError(t, err)
+
+	var parsed sigstoreBundleJSON
+	require.NoError(t, json.Unmarshal(data, &parsed))
+	require.Len(t, parsed.VerificationMaterial.TlogEntries, 1)
+
+	entry, err := decodeTlogEntry(parsed.VerificationMaterial.TlogEntries[0])
+	require.NoError(t, err)
+	require.Equal(t, int64(42), entry.LogIndex)
+	require.NoError(t, entry.VerifyInclusion())
+}
+
+// TestLoadSigstoreBundleMissingFile checks the not-found error path used
+// whenever a --bundle flag points at a path that doesn't exist.
+func TestLoadSigstoreBundleMissingFile(t *testing.T) {
+	_, err := LoadSigstoreBundle(filepath.Join(t.TempDir(), "missing.sigstore.json"))
+	require.Error(t, err)
+}
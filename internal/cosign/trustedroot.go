@@ -0,0 +1,277 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	upstreamcosign "github.com/sigstore/cosign/v2/pkg/cosign"
+)
+
+// trustedRootValidity mirrors the "validFor" object that appears on
+// certificate authorities, transparency log keys and timestamp authorities
+// in a Sigstore trusted_root.json bundle. End is omitted while the key or CA
+// is still in use.
+type trustedRootValidity struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+func (v trustedRootValidity) contains(t time.Time) bool {
+	if t.Before(v.Start) {
+		return false
+	}
+	return v.End == nil || t.Before(*v.End)
+}
+
+type trustedRootCertificate struct {
+	RawBytes string `json:"rawBytes"`
+}
+
+type trustedRootCertificateAuthority struct {
+	CertChain struct {
+		Certificates []trustedRootCertificate `json:"certificates"`
+	} `json:"certChain"`
+	ValidFor trustedRootValidity `json:"validFor"`
+}
+
+type trustedRootTransparencyLogKey struct {
+	PublicKey struct {
+		RawBytes string `json:"rawBytes"`
+	} `json:"publicKey"`
+	ValidFor trustedRootValidity `json:"validFor"`
+}
+
+// trustedRoot is the subset of the Sigstore trust-bundle format
+// (https://github.com/sigstore/protobuf-specs TrustedRoot message) that
+// falcoctl needs to verify signatures fully offline.
+type trustedRoot struct {
+	MediaType              string                            `json:"mediaType"`
+	CertificateAuthorities []trustedRootCertificateAuthority `json:"certificateAuthorities"`
+	CTLogs                 []trustedRootTransparencyLogKey   `json:"ctlogs"`
+	TLogs                  []trustedRootTransparencyLogKey   `json:"tlogs"`
+	TimestampAuthorities   []trustedRootCertificateAuthority `json:"timestampAuthorities"`
+}
+
+// TrustedRootMaterial is the trust material selected out of a trusted_root.json
+// bundle for a single point in time (usually a signature's transparency log
+// integrated time).
+type TrustedRootMaterial struct {
+	RootCerts         *x509.CertPool
+	IntermediateCerts *x509.CertPool
+	CTLogPubKeys      map[string]upstreamcosign.TransparencyLogPubKey
+	RekorPubKeys      map[string]upstreamcosign.TransparencyLogPubKey
+	TSACertificate    *x509.Certificate
+	TSAIntermediates  []*x509.Certificate
+	TSARootCerts      []*x509.Certificate
+}
+
+// loadTrustedRootFile parses a trusted_root.json file.
+func loadTrustedRootFile(path string) (*trustedRoot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading trusted root %q: %w", path, err)
+	}
+	var tr trustedRoot
+	if err := json.Unmarshal(data, &tr); err != nil {
+		return nil, fmt.Errorf("parsing trusted root %q: %w", path, err)
+	}
+	return &tr, nil
+}
+
+// selectTrustedRootMaterial picks, out of every CA/key listed in tr, the ones
+// whose validity window contains at. When more than one Fulcio CA chain is
+// valid at that time (e.g. during a planned rotation) all of them are added
+// to the returned pools so verification can be attempted against either.
+func selectTrustedRootMaterial(tr *trustedRoot, at time.Time) (*TrustedRootMaterial, error) {
+	m := &TrustedRootMaterial{
+		RootCerts:         x509.NewCertPool(),
+		IntermediateCerts: x509.NewCertPool(),
+		CTLogPubKeys:      map[string]upstreamcosign.TransparencyLogPubKey{},
+		RekorPubKeys:      map[string]upstreamcosign.TransparencyLogPubKey{},
+	}
+
+	for _, ca := range tr.CertificateAuthorities {
+		if !ca.ValidFor.contains(at) {
+			continue
+		}
+		certs, err := decodeCertChain(ca.CertChain.Certificates)
+		if err != nil {
+			return nil, fmt.Errorf("decoding certificate authority chain: %w", err)
+		}
+		if len(certs) == 0 {
+			continue
+		}
+		m.RootCerts.AddCert(certs[len(certs)-1])
+		for _, c := range certs[:len(certs)-1] {
+			m.IntermediateCerts.AddCert(c)
+		}
+	}
+
+	for _, ctlog := range tr.CTLogs {
+		if !ctlog.ValidFor.contains(at) {
+			continue
+		}
+		pub, keyID, err := decodeTransparencyLogKey(ctlog.PublicKey.RawBytes)
+		if err != nil {
+			return nil, fmt.Errorf("decoding ctlog public key: %w", err)
+		}
+		m.CTLogPubKeys[keyID] = upstreamcosign.TransparencyLogPubKey{PubKey: pub, Status: upstreamcosign.TlogStatusActive}
+	}
+
+	for _, tlog := range tr.TLogs {
+		if !tlog.ValidFor.contains(at) {
+			continue
+		}
+		pub, keyID, err := decodeTransparencyLogKey(tlog.PublicKey.RawBytes)
+		if err != nil {
+			return nil, fmt.Errorf("decoding rekor public key: %w", err)
+		}
+		m.RekorPubKeys[keyID] = upstreamcosign.TransparencyLogPubKey{PubKey: pub, Status: upstreamcosign.TlogStatusActive}
+	}
+
+	for _, tsa := range tr.TimestampAuthorities {
+		if !tsa.ValidFor.contains(at) {
+			continue
+		}
+		certs, err := decodeCertChain(tsa.CertChain.Certificates)
+		if err != nil {
+			return nil, fmt.Errorf("decoding TSA chain: %w", err)
+		}
+		if len(certs) == 0 {
+			continue
+		}
+		m.TSACertificate = certs[0]
+		m.TSARootCerts = []*x509.Certificate{certs[len(certs)-1]}
+		if len(certs) > 2 {
+			m.TSAIntermediates = certs[1 : len(certs)-1]
+		}
+	}
+
+	return m, nil
+}
+
+func decodeCertChain(certs []trustedRootCertificate) ([]*x509.Certificate, error) {
+	out := make([]*x509.Certificate, 0, len(certs))
+	for _, c := range certs {
+		der, err := base64.StdEncoding.DecodeString(c.RawBytes)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cert)
+	}
+	return out, nil
+}
+
+func decodeTransparencyLogKey(rawBase64 string) (crypto.PublicKey, string, error) {
+	der, err := base64.StdEncoding.DecodeString(rawBase64)
+	if err != nil {
+		return nil, "", err
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, "", err
+	}
+	// Rekor/cosign identify a transparency log key by the hex-encoded
+	// SHA-256 digest of its PKIX-encoded public key, not its SKID; this must
+	// match both cosign.GetRekorPubs/GetCTLogPubs and how bundle.go's
+	// decodeTlogEntry builds TlogEntry.LogID, since they all key the same map.
+	keyID := sha256.Sum256(der)
+	return pub, hex.EncodeToString(keyID[:]), nil
+}
+
+// LoadTrustedRoot parses a Sigstore trusted_root.json bundle and selects the
+// trust material valid at the given time, ready to be assigned onto a
+// cosign.CheckOpts.
+func LoadTrustedRoot(path string, at time.Time) (*TrustedRootMaterial, error) {
+	tr, err := loadTrustedRootFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return selectTrustedRootMaterial(tr, at)
+}
+
+// TrustedCertChainInput is one Fulcio or TSA certificate chain to include in
+// an assembled trusted_root.json, pinned to an explicit validity window.
+type TrustedCertChainInput struct {
+	Certs     []*x509.Certificate
+	ValidFrom time.Time
+	ValidTo   *time.Time
+}
+
+// TrustedKeyInput is one CT log or Rekor public key to include in an
+// assembled trusted_root.json, pinned to an explicit validity window.
+type TrustedKeyInput struct {
+	// PublicKeyDER is the PKIX-encoded public key.
+	PublicKeyDER []byte
+	ValidFrom    time.Time
+	ValidTo      *time.Time
+}
+
+// AssembleTrustedRoot builds the JSON document for a trusted_root.json bundle
+// out of locally supplied PEM/DER trust material, so air-gapped deployments
+// can pin and rotate their own roots without depending on the TUF root.
+func AssembleTrustedRoot(fulcio, tsa []TrustedCertChainInput, ctlogs, tlogs []TrustedKeyInput) ([]byte, error) {
+	tr := trustedRoot{
+		MediaType: "application/vnd.dev.sigstore.trustedroot+json;version=0.1",
+	}
+
+	for _, in := range fulcio {
+		tr.CertificateAuthorities = append(tr.CertificateAuthorities, encodeCertChainInput(in))
+	}
+	for _, in := range tsa {
+		tr.TimestampAuthorities = append(tr.TimestampAuthorities, encodeCertChainInput(in))
+	}
+	for _, in := range ctlogs {
+		tr.CTLogs = append(tr.CTLogs, encodeKeyInput(in))
+	}
+	for _, in := range tlogs {
+		tr.TLogs = append(tr.TLogs, encodeKeyInput(in))
+	}
+
+	return json.MarshalIndent(tr, "", "  ")
+}
+
+func encodeCertChainInput(in TrustedCertChainInput) trustedRootCertificateAuthority {
+	ca := trustedRootCertificateAuthority{
+		ValidFor: trustedRootValidity{Start: in.ValidFrom, End: in.ValidTo},
+	}
+	for _, cert := range in.Certs {
+		ca.CertChain.Certificates = append(ca.CertChain.Certificates, trustedRootCertificate{
+			RawBytes: base64.StdEncoding.EncodeToString(cert.Raw),
+		})
+	}
+	return ca
+}
+
+func encodeKeyInput(in TrustedKeyInput) trustedRootTransparencyLogKey {
+	k := trustedRootTransparencyLogKey{
+		ValidFor: trustedRootValidity{Start: in.ValidFrom, End: in.ValidTo},
+	}
+	k.PublicKey.RawBytes = base64.StdEncoding.EncodeToString(in.PublicKeyDER)
+	return k
+}
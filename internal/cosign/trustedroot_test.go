@@ -0,0 +1,132 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func selfSignedCert(t *testing.T, cn string, notBefore, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// TestLoadTrustedRootSelectsMaterialValidAtTime builds a trusted_root.json
+// with two non-overlapping Fulcio CA validity windows (as happens around a
+// planned rotation) and checks that LoadTrustedRoot picks the CA that was
+// valid at the requested time, not "now".
+func TestLoadTrustedRootSelectsMaterialValidAtTime(t *testing.T) {
+	oldWindow := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newWindow := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	oldEnd := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	oldCert := selfSignedCert(t, "old-fulcio", oldWindow, oldEnd.AddDate(1, 0, 0))
+	newCert := selfSignedCert(t, "new-fulcio", newWindow, newWindow.AddDate(10, 0, 0))
+
+	data, err := AssembleTrustedRoot(
+		[]TrustedCertChainInput{
+			{Certs: []*x509.Certificate{oldCert}, ValidFrom: oldWindow, ValidTo: &oldEnd},
+			{Certs: []*x509.Certificate{newCert}, ValidFrom: newWindow},
+		},
+		nil, nil, nil,
+	)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "trusted_root.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	atOld := oldWindow.AddDate(0, 6, 0)
+	material, err := LoadTrustedRoot(path, atOld)
+	require.NoError(t, err)
+	require.True(t, material.RootCerts.Equal(certPool(oldCert)))
+
+	atNew := newWindow.AddDate(0, 6, 0)
+	material, err = LoadTrustedRoot(path, atNew)
+	require.NoError(t, err)
+	require.True(t, material.RootCerts.Equal(certPool(newCert)))
+}
+
+// TestLoadTrustedRootKeysTransparencyLogByDigest checks that the Rekor/CT
+// log public key maps LoadTrustedRoot returns are keyed by the hex-encoded
+// SHA-256 digest of the key's PKIX DER encoding, since that's the log ID a
+// real TlogEntry.LogID (hex.EncodeToString of the bundle's logId.keyId) and
+// cosign.GetRekorPubs/GetCTLogPubs both use to look entries up.
+func TestLoadTrustedRootKeysTransparencyLogByDigest(t *testing.T) {
+	rekorKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rekorDER, err := x509.MarshalPKIXPublicKey(&rekorKey.PublicKey)
+	require.NoError(t, err)
+
+	ctlogKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ctlogDER, err := x509.MarshalPKIXPublicKey(&ctlogKey.PublicKey)
+	require.NoError(t, err)
+
+	validFrom := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, err := AssembleTrustedRoot(
+		nil, nil,
+		[]TrustedKeyInput{{PublicKeyDER: ctlogDER, ValidFrom: validFrom}},
+		[]TrustedKeyInput{{PublicKeyDER: rekorDER, ValidFrom: validFrom}},
+	)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "trusted_root.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	material, err := LoadTrustedRoot(path, validFrom.AddDate(0, 1, 0))
+	require.NoError(t, err)
+
+	rekorDigest := sha256.Sum256(rekorDER)
+	rekorLogID := hex.EncodeToString(rekorDigest[:])
+	require.Contains(t, material.RekorPubKeys, rekorLogID)
+
+	ctlogDigest := sha256.Sum256(ctlogDER)
+	ctlogLogID := hex.EncodeToString(ctlogDigest[:])
+	require.Contains(t, material.CTLogPubKeys, ctlogLogID)
+}
+
+func certPool(certs ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, c := range certs {
+		pool.AddCert(c)
+	}
+	return pool
+}
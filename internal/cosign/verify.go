@@ -19,13 +19,19 @@ import (
 	"bytes"
 	"context"
 	"crypto"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/sigstore/cosign/v2/cmd/cosign/cli/fulcio"
@@ -37,6 +43,7 @@ import (
 	"github.com/sigstore/cosign/v2/pkg/cosign"
 	"github.com/sigstore/cosign/v2/pkg/cosign/pivkey"
 	"github.com/sigstore/cosign/v2/pkg/cosign/pkcs11key"
+	"github.com/sigstore/cosign/v2/pkg/oci"
 	sigs "github.com/sigstore/cosign/v2/pkg/signature"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
 	"github.com/sigstore/sigstore/pkg/signature"
@@ -72,7 +79,37 @@ type VerifyCommand struct {
 	NameOptions                  []name.Option
 	Offline                      bool
 	TSACertChainPath             string
+	RFC3161TimestampPath         string
 	IgnoreTlog                   bool
+	CertExtensions               map[string]string
+	TrustedRootPath              string
+	BundlePath                   string
+}
+
+// certExtensionOIDs maps the human-readable Fulcio certificate extension
+// names accepted on the command line to their well-known OIDs, as defined by
+// https://github.com/sigstore/fulcio/blob/main/docs/oid-info.md.
+var certExtensionOIDs = map[string]asn1.ObjectIdentifier{
+	"issuer":                              {1, 3, 6, 1, 4, 1, 57264, 1, 8},
+	"githubWorkflowTrigger":               {1, 3, 6, 1, 4, 1, 57264, 1, 2},
+	"githubWorkflowSha":                   {1, 3, 6, 1, 4, 1, 57264, 1, 3},
+	"githubWorkflowName":                  {1, 3, 6, 1, 4, 1, 57264, 1, 4},
+	"githubWorkflowRepository":            {1, 3, 6, 1, 4, 1, 57264, 1, 5},
+	"githubWorkflowRef":                   {1, 3, 6, 1, 4, 1, 57264, 1, 6},
+	"buildSignerURI":                      {1, 3, 6, 1, 4, 1, 57264, 1, 9},
+	"buildSignerDigest":                   {1, 3, 6, 1, 4, 1, 57264, 1, 10},
+	"runnerEnvironment":                   {1, 3, 6, 1, 4, 1, 57264, 1, 11},
+	"sourceRepositoryURI":                 {1, 3, 6, 1, 4, 1, 57264, 1, 12},
+	"sourceRepositoryDigest":              {1, 3, 6, 1, 4, 1, 57264, 1, 13},
+	"sourceRepositoryRef":                 {1, 3, 6, 1, 4, 1, 57264, 1, 14},
+	"sourceRepositoryIdentifier":          {1, 3, 6, 1, 4, 1, 57264, 1, 15},
+	"sourceRepositoryOwnerURI":            {1, 3, 6, 1, 4, 1, 57264, 1, 16},
+	"sourceRepositoryOwnerIdentifier":     {1, 3, 6, 1, 4, 1, 57264, 1, 17},
+	"buildConfigURI":                      {1, 3, 6, 1, 4, 1, 57264, 1, 18},
+	"buildConfigDigest":                   {1, 3, 6, 1, 4, 1, 57264, 1, 19},
+	"buildTrigger":                        {1, 3, 6, 1, 4, 1, 57264, 1, 20},
+	"runInvocationURI":                    {1, 3, 6, 1, 4, 1, 57264, 1, 21},
+	"sourceRepositoryVisibilityAtSigning": {1, 3, 6, 1, 4, 1, 57264, 1, 22},
 }
 
 func (c *VerifyCommand) DoVerify(ctx context.Context, images []string) (err error) {
@@ -124,8 +161,69 @@ func (c *VerifyCommand) DoVerify(ctx context.Context, images []string) (err erro
 		co.ClaimVerifier = cosign.SimpleClaimVerifier
 	}
 
+	var bundle *SigstoreBundle
+	if c.BundlePath != "" {
+		bundle, err = LoadSigstoreBundle(c.BundlePath)
+		if err != nil {
+			return fmt.Errorf("loading sigstore bundle: %w", err)
+		}
+		if len(bundle.RFC3161Timestamp) > 0 {
+			co.RFC3161Timestamp = &cosign.RFC3161Timestamp{SignedRFC3161Timestamp: bundle.RFC3161Timestamp}
+		}
+		sigPath, err := writeTempSignature(bundle.Signature)
+		if err != nil {
+			return fmt.Errorf("staging bundled signature: %w", err)
+		}
+		defer os.Remove(sigPath)
+		co.SignatureRef = sigPath
+	}
+
 	if c.TSACertChainPath != "" {
-		return fmt.Errorf("TSA cert chains are not supported in this tool")
+		tsaCertChain, err := loadCertChainFromFileOrURL(c.TSACertChainPath)
+		if err != nil {
+			return fmt.Errorf("loading TSA certificate chain: %w", err)
+		}
+		// The chain is ordered leaf, intermediates..., root.
+		co.TSACertificate = tsaCertChain[0]
+		co.TSARootCertificates = []*x509.Certificate{tsaCertChain[len(tsaCertChain)-1]}
+		if len(tsaCertChain) > 2 {
+			co.TSAIntermediateCertificates = tsaCertChain[1 : len(tsaCertChain)-1]
+		}
+	}
+
+	if c.RFC3161TimestampPath != "" {
+		if c.TSACertChainPath == "" {
+			return fmt.Errorf("a TSA certificate chain (TSACertChainPath) must be provided to verify an RFC3161 timestamp")
+		}
+		ts, err := os.ReadFile(filepath.Clean(c.RFC3161TimestampPath))
+		if err != nil {
+			return fmt.Errorf("reading RFC3161 timestamp: %w", err)
+		}
+		co.RFC3161Timestamp = &cosign.RFC3161Timestamp{
+			SignedRFC3161Timestamp: ts,
+		}
+	}
+
+	var trustedRoot *TrustedRootMaterial
+	if c.TrustedRootPath != "" {
+		// A trusted_root.json bundle pins every piece of trust material
+		// (Fulcio chains, CT log keys, Rekor keys and TSA chains) with
+		// explicit validity windows. Select the material that was valid when
+		// the signature was actually made (the bundle's tlog integrated
+		// time), not at verification time, so an artifact signed before a
+		// planned trust material rotation still verifies after it. Without a
+		// bundle there is no integrated time to use, and the material valid
+		// now is selected.
+		at := time.Now()
+		if bundle != nil {
+			if integratedTime, ok := bundle.IntegratedTime(); ok {
+				at = integratedTime
+			}
+		}
+		trustedRoot, err = LoadTrustedRoot(c.TrustedRootPath, at)
+		if err != nil {
+			return fmt.Errorf("loading trusted root: %w", err)
+		}
 	}
 
 	if !c.IgnoreTlog {
@@ -136,15 +234,28 @@ func (c *VerifyCommand) DoVerify(ctx context.Context, images []string) (err erro
 			}
 			co.RekorClient = rekorClient
 		}
-		// This performs an online fetch of the Rekor public keys, but this is needed
-		// for verifying tlog entries (both online and offline).
-		co.RekorPubKeys, err = cosign.GetRekorPubs(ctx)
-		if err != nil {
-			return fmt.Errorf("getting Rekor public keys: %w", err)
+		if trustedRoot != nil {
+			co.RekorPubKeys = trustedRoot.RekorPubKeys
+		} else {
+			// This performs an online fetch of the Rekor public keys, but this is needed
+			// for verifying tlog entries (both online and offline).
+			co.RekorPubKeys, err = cosign.GetRekorPubs(ctx)
+			if err != nil {
+				return fmt.Errorf("getting Rekor public keys: %w", err)
+			}
+		}
+		if bundle != nil {
+			if len(bundle.TlogEntries) == 0 {
+				return fmt.Errorf("bundle does not carry a Rekor transparency log entry")
+			}
+			if err := verifyTlogInclusion(bundle.TlogEntries[0], co.RekorPubKeys); err != nil {
+				return fmt.Errorf("verifying Rekor inclusion proof: %w", err)
+			}
 		}
 	}
 	if keylessVerification(c.KeyRef, c.Sk) {
-		if c.CertChain != "" {
+		switch {
+		case c.CertChain != "":
 			chain, err := loadCertChainFromFileOrURL(c.CertChain)
 			if err != nil {
 				return err
@@ -157,7 +268,10 @@ func (c *VerifyCommand) DoVerify(ctx context.Context, images []string) (err erro
 					co.IntermediateCerts.AddCert(cert)
 				}
 			}
-		} else {
+		case trustedRoot != nil:
+			co.RootCerts = trustedRoot.RootCerts
+			co.IntermediateCerts = trustedRoot.IntermediateCerts
+		default:
 			// This performs an online fetch of the Fulcio roots. This is needed
 			// for verifying keyless certificates (both online and offline).
 			co.RootCerts, err = fulcio.GetRoots()
@@ -170,19 +284,35 @@ func (c *VerifyCommand) DoVerify(ctx context.Context, images []string) (err erro
 			}
 		}
 	}
+
 	keyRef := c.KeyRef
 	certRef := c.CertRef
 
 	if !c.IgnoreSCT {
-		co.CTLogPubKeys, err = cosign.GetCTLogPubs(ctx)
-		if err != nil {
-			return fmt.Errorf("getting ctlog public keys: %w", err)
+		if trustedRoot != nil {
+			co.CTLogPubKeys = trustedRoot.CTLogPubKeys
+		} else {
+			co.CTLogPubKeys, err = cosign.GetCTLogPubs(ctx)
+			if err != nil {
+				return fmt.Errorf("getting ctlog public keys: %w", err)
+			}
 		}
 	}
 
+	if trustedRoot != nil && c.TSACertChainPath == "" && trustedRoot.TSACertificate != nil {
+		co.TSACertificate = trustedRoot.TSACertificate
+		co.TSARootCertificates = trustedRoot.TSARootCerts
+		co.TSAIntermediateCertificates = trustedRoot.TSAIntermediates
+	}
+
 	// Keys are optional!
 	var pubKey signature.Verifier
 	switch {
+	case bundle != nil:
+		pubKey, err = cosign.ValidateAndUnpackCert(bundle.Cert, co)
+		if err != nil {
+			return err
+		}
 	case keyRef != "":
 		pubKey, err = sigs.PublicKeyFromKeyRefWithHashAlgo(ctx, keyRef, c.HashAlgorithm)
 		if err != nil {
@@ -251,8 +381,9 @@ func (c *VerifyCommand) DoVerify(ctx context.Context, images []string) (err erro
 	// fulcioVerified := (co.SigVerifier == nil)
 
 	for _, img := range images {
+		var checkedSignatures []oci.Signature
 		if c.LocalImage {
-			_, _, err := cosign.VerifyLocalImageSignatures(ctx, img, co)
+			checkedSignatures, _, err = cosign.VerifyLocalImageSignatures(ctx, img, co)
 			if err != nil {
 				return err
 			}
@@ -261,21 +392,202 @@ func (c *VerifyCommand) DoVerify(ctx context.Context, images []string) (err erro
 			if err != nil {
 				return fmt.Errorf("parsing reference: %w", err)
 			}
-			ref, err = sign.GetAttachedImageRef(ref, c.Attachment, ociremoteOpts...)
-			if err != nil {
-				return fmt.Errorf("resolving attachment type %s for image %s: %w", c.Attachment, img, err)
+			if bundle == nil {
+				// The bundle already carries the signature and certificate for this
+				// exact digest, so there's no attachment to resolve and no need for
+				// any further registry round-trips.
+				ref, err = sign.GetAttachedImageRef(ref, c.Attachment, ociremoteOpts...)
+				if err != nil {
+					return fmt.Errorf("resolving attachment type %s for image %s: %w", c.Attachment, img, err)
+				}
 			}
 
-			_, _, err = cosign.VerifyImageSignatures(ctx, ref, co)
+			checkedSignatures, _, err = cosign.VerifyImageSignatures(ctx, ref, co)
 			if err != nil {
 				return cosignError.WrapError(err)
 			}
 		}
+
+		if len(c.CertExtensions) > 0 {
+			if err := verifyCertExtensions(checkedSignatures, c.CertExtensions); err != nil {
+				return fmt.Errorf("verifying certificate extensions for %s: %w", img, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// DoVerifyBlob verifies a standalone blob against a Sigstore bundle (set via
+// BundlePath): the blob's digest is checked against the one recorded in the
+// bundle, and the bundle's signature is verified against the public key of
+// its signing certificate. With TrustedRootPath set this needs no network
+// access; without it the Fulcio roots and Rekor public keys are fetched
+// online. This is the companion to DoVerify for artifacts that aren't pushed
+// as OCI images, e.g. a bare rules file.
+func (c *VerifyCommand) DoVerifyBlob(ctx context.Context, blobPath string) (err error) {
+	if c.BundlePath == "" {
+		return fmt.Errorf("a sigstore bundle is required for blob verification")
+	}
+	bundle, err := LoadSigstoreBundle(c.BundlePath)
+	if err != nil {
+		return fmt.Errorf("loading sigstore bundle: %w", err)
+	}
+
+	blobBytes, err := os.ReadFile(filepath.Clean(blobPath))
+	if err != nil {
+		return fmt.Errorf("reading blob %q: %w", blobPath, err)
+	}
+
+	digest := sha256.Sum256(blobBytes)
+	if hex.EncodeToString(digest[:]) != bundle.MessageDigestHex {
+		return fmt.Errorf("blob %q does not match the digest recorded in the bundle", blobPath)
+	}
+
+	co := &cosign.CheckOpts{
+		IgnoreTlog: c.IgnoreTlog,
+	}
+
+	var trustedRoot *TrustedRootMaterial
+	if c.TrustedRootPath != "" {
+		// Select the trust material valid at the bundle's tlog integrated
+		// time, not at verification time, so the bundle still verifies
+		// after a planned Fulcio/CT-log/TSA rotation.
+		at := time.Now()
+		if integratedTime, ok := bundle.IntegratedTime(); ok {
+			at = integratedTime
+		}
+		trustedRoot, err = LoadTrustedRoot(c.TrustedRootPath, at)
+		if err != nil {
+			return fmt.Errorf("loading trusted root: %w", err)
+		}
+		co.RootCerts = trustedRoot.RootCerts
+		co.IntermediateCerts = trustedRoot.IntermediateCerts
+		co.CTLogPubKeys = trustedRoot.CTLogPubKeys
+		co.RekorPubKeys = trustedRoot.RekorPubKeys
+	} else {
+		co.RootCerts, err = fulcio.GetRoots()
+		if err != nil {
+			return fmt.Errorf("getting Fulcio roots: %w", err)
+		}
+		co.IntermediateCerts, err = fulcio.GetIntermediates()
+		if err != nil {
+			return fmt.Errorf("getting Fulcio intermediates: %w", err)
+		}
+		if !c.IgnoreTlog {
+			co.RekorPubKeys, err = cosign.GetRekorPubs(ctx)
+			if err != nil {
+				return fmt.Errorf("getting Rekor public keys: %w", err)
+			}
+		}
+	}
+
+	if !c.IgnoreTlog {
+		if len(bundle.TlogEntries) == 0 {
+			return fmt.Errorf("bundle does not carry a Rekor transparency log entry")
+		}
+		if err := verifyTlogInclusion(bundle.TlogEntries[0], co.RekorPubKeys); err != nil {
+			return fmt.Errorf("verifying Rekor inclusion proof: %w", err)
+		}
+	}
+
+	pubKey, err := cosign.ValidateAndUnpackCert(bundle.Cert, co)
+	if err != nil {
+		return fmt.Errorf("validating signing certificate: %w", err)
+	}
+
+	return pubKey.VerifySignature(bytes.NewReader(bundle.Signature), bytes.NewReader(blobBytes))
+}
+
+// verifyTlogInclusion checks that entry is genuinely part of the Rekor
+// transparency log identified by its logID: the Merkle inclusion proof
+// demonstrates that entry is in the tree with the claimed root hash, and the
+// checkpoint signature demonstrates that root hash was actually signed by a
+// trusted Rekor key, not forged by whoever assembled the bundle.
+func verifyTlogInclusion(entry TlogEntry, rekorPubKeys map[string]cosign.TransparencyLogPubKey) error {
+	if err := entry.VerifyInclusion(); err != nil {
+		return fmt.Errorf("invalid Merkle inclusion proof: %w", err)
+	}
+
+	pub, ok := rekorPubKeys[entry.LogID]
+	if !ok {
+		return fmt.Errorf("tlog entry was logged by an unrecognized Rekor key (keyID %s)", entry.LogID)
 	}
 
+	if err := verifyCheckpointSignature(entry.InclusionProof.Checkpoint, pub.PubKey); err != nil {
+		return err
+	}
+
+	// The signed checkpoint body starts with "<origin>\n<tree size>\n<base64 root hash>\n".
+	lines := strings.SplitN(entry.InclusionProof.Checkpoint, "\n", 4)
+	if len(lines) < 4 ||
+		lines[1] != strconv.FormatInt(entry.InclusionProof.TreeSize, 10) ||
+		lines[2] != base64.StdEncoding.EncodeToString(entry.InclusionProof.RootHash) {
+		return fmt.Errorf("signed checkpoint does not match the inclusion proof's tree size and root hash")
+	}
 	return nil
 }
 
+// verifyCheckpointSignature verifies a Rekor checkpoint in the signed note
+// format (https://github.com/C2SP/C2SP/blob/main/signed-note.md): the
+// checkpoint body is everything up to and including the newline that ends
+// its last line, just before the blank line that separates it from its
+// signatures, and each "— <name> <base64(keyHash+sig)>"
+// line after that blank line is a detached signature over that body.
+func verifyCheckpointSignature(checkpoint string, pub crypto.PublicKey) error {
+	if checkpoint == "" {
+		return fmt.Errorf("inclusion proof does not carry a signed checkpoint")
+	}
+
+	const sep = "\n\n"
+	idx := strings.Index(checkpoint, sep)
+	if idx < 0 {
+		return fmt.Errorf("malformed checkpoint: missing body/signature separator")
+	}
+	body := checkpoint[:idx+1]
+
+	verifier, err := signature.LoadVerifier(pub, crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("loading Rekor verifier: %w", err)
+	}
+
+	for _, line := range strings.Split(checkpoint[idx+len(sep):], "\n") {
+		const sigLinePrefix = "— "
+		if !strings.HasPrefix(line, sigLinePrefix) {
+			continue
+		}
+		fields := strings.SplitN(strings.TrimPrefix(line, sigLinePrefix), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		sigBytes, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(sigBytes) <= 4 {
+			continue
+		}
+		// The first 4 bytes are a key hint, not part of the signature itself.
+		if verifier.VerifySignature(bytes.NewReader(sigBytes[4:]), strings.NewReader(body)) == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no checkpoint signature verified against the trusted Rekor public key")
+}
+
+// writeTempSignature stages raw signature bytes in a temp file so they can be
+// referenced via CheckOpts.SignatureRef, which cosign otherwise expects to
+// load from disk or a registry annotation.
+func writeTempSignature(sig []byte) (string, error) {
+	f, err := os.CreateTemp("", "falcoctl-bundle-sig-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(sig); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
 func loadCertFromFileOrURL(path string) (*x509.Certificate, error) {
 	pems, err := blob.LoadFileOrURL(path)
 	if err != nil {
@@ -314,6 +626,62 @@ func loadCertChainFromFileOrURL(path string) ([]*x509.Certificate, error) {
 	return certs, nil
 }
 
+// verifyCertExtensions checks that the leaf certificate attached to at least
+// one of the checked signatures carries every extension in want, matching the
+// requested values exactly. All extensions must match on the same signer's
+// certificate: a caller that pins e.g. githubWorkflowRepository and
+// buildConfigURI must not be able to satisfy them from two different signers.
+// An unknown extension name fails verification immediately; a missing or
+// mismatched value on every certificate does too.
+func verifyCertExtensions(signatures []oci.Signature, want map[string]string) error {
+	oids := make(map[string]asn1.ObjectIdentifier, len(want))
+	for extName := range want {
+		oid, ok := certExtensionOIDs[extName]
+		if !ok {
+			return fmt.Errorf("unknown certificate extension %q", extName)
+		}
+		oids[extName] = oid
+	}
+
+	for _, sig := range signatures {
+		cert, err := sig.Cert()
+		if err != nil || cert == nil {
+			continue
+		}
+
+		matchesAll := true
+		for extName, wantValue := range want {
+			gotValue, ok := extensionValue(cert, oids[extName])
+			if !ok || gotValue != wantValue {
+				matchesAll = false
+				break
+			}
+		}
+		if matchesAll {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no checked signature's certificate matches all of the required certificate extensions %v", want)
+}
+
+// extensionValue returns the decoded value of the first certificate extension
+// matching oid. Fulcio encodes most extensions as ASN.1 UTF8Strings; fall back
+// to the raw bytes for the few that are plain strings.
+func extensionValue(cert *x509.Certificate, oid asn1.ObjectIdentifier) (string, bool) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oid) {
+			continue
+		}
+		var value string
+		if _, err := asn1.Unmarshal(ext.Value, &value); err == nil {
+			return value, true
+		}
+		return string(ext.Value), true
+	}
+	return "", false
+}
+
 func keylessVerification(keyRef string, sk bool) bool {
 	if keyRef != "" {
 		return false
@@ -0,0 +1,142 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cosign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/oci"
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSignature is an oci.Signature that only implements Cert, which is all
+// verifyCertExtensions needs; every other method panics if called.
+type fakeSignature struct {
+	oci.Signature
+	cert *x509.Certificate
+}
+
+func (f fakeSignature) Cert() (*x509.Certificate, error) {
+	return f.cert, nil
+}
+
+func certWithExtensions(t *testing.T, values map[string]string) *x509.Certificate {
+	t.Helper()
+	cert := &x509.Certificate{}
+	for name, value := range values {
+		oid, ok := certExtensionOIDs[name]
+		require.True(t, ok, "unknown test extension %q", name)
+		encoded, err := asn1.Marshal(value)
+		require.NoError(t, err)
+		cert.Extensions = append(cert.Extensions, pkix.Extension{Id: oid, Value: encoded})
+	}
+	return cert
+}
+
+func TestVerifyCertExtensionsRequiresAllOnSameSigner(t *testing.T) {
+	want := map[string]string{
+		"issuer":                   "https://token.actions.githubusercontent.com",
+		"githubWorkflowRepository": "falcosecurity/falcoctl",
+	}
+
+	// Two signatures, each matching exactly one of the two wanted
+	// extensions: neither signer alone satisfies the policy, and the
+	// union-across-signers behavior this fix removed must not pass either.
+	partial1 := fakeSignature{cert: certWithExtensions(t, map[string]string{
+		"issuer": "https://token.actions.githubusercontent.com",
+	})}
+	partial2 := fakeSignature{cert: certWithExtensions(t, map[string]string{
+		"githubWorkflowRepository": "falcosecurity/falcoctl",
+	})}
+
+	err := verifyCertExtensions([]oci.Signature{partial1, partial2}, want)
+	require.Error(t, err)
+}
+
+func TestVerifyCertExtensionsMatchesSingleSigner(t *testing.T) {
+	want := map[string]string{
+		"issuer":                   "https://token.actions.githubusercontent.com",
+		"githubWorkflowRepository": "falcosecurity/falcoctl",
+	}
+
+	partial := fakeSignature{cert: certWithExtensions(t, map[string]string{
+		"issuer": "https://token.actions.githubusercontent.com",
+	})}
+	full := fakeSignature{cert: certWithExtensions(t, want)}
+
+	err := verifyCertExtensions([]oci.Signature{partial, full}, want)
+	require.NoError(t, err)
+}
+
+// signedCheckpoint builds a signed-note-format checkpoint
+// (https://github.com/C2SP/C2SP/blob/main/signed-note.md) whose body is
+// bodyText, signed by priv and attributed to name.
+func signedCheckpoint(t *testing.T, priv *ecdsa.PrivateKey, name, bodyText string) string {
+	t.Helper()
+	signer, err := signature.LoadECDSASignerVerifier(priv, crypto.SHA256)
+	require.NoError(t, err)
+	sig, err := signer.SignMessage(strings.NewReader(bodyText))
+	require.NoError(t, err)
+
+	keyHint := make([]byte, 4)
+	return bodyText + "\n— " + name + " " + base64.StdEncoding.EncodeToString(append(keyHint, sig...)) + "\n"
+}
+
+func TestVerifyTlogInclusionAcceptsGenuineCheckpointAndRejectsTampering(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	const logID = "test-rekor-log-id"
+	rekorPubKeys := map[string]cosign.TransparencyLogPubKey{
+		logID: {PubKey: &priv.PublicKey, Status: cosign.TlogStatusActive},
+	}
+
+	leaf := []byte("hashedrekord entry")
+	checkpointBody := "test-rekor-log - 1\n1\n" + base64.StdEncoding.EncodeToString(hashLeaf(leaf)) + "\n"
+	entry := TlogEntry{
+		LogID:             logID,
+		CanonicalizedBody: leaf,
+		InclusionProof: &InclusionProof{
+			LogIndex:   0,
+			TreeSize:   1,
+			RootHash:   hashLeaf(leaf),
+			Checkpoint: signedCheckpoint(t, priv, "test-rekor-log", checkpointBody),
+		},
+	}
+
+	require.NoError(t, verifyTlogInclusion(entry, rekorPubKeys))
+
+	// A checkpoint signed by an unrelated key must be rejected: it's not
+	// actually attested by the Rekor key the caller trusts.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tampered := entry
+	tamperedProof := *entry.InclusionProof
+	tamperedProof.Checkpoint = signedCheckpoint(t, otherKey, "test-rekor-log", checkpointBody)
+	tampered.InclusionProof = &tamperedProof
+
+	require.Error(t, verifyTlogInclusion(tampered, rekorPubKeys))
+}
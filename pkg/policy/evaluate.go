@@ -0,0 +1,125 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sigs "github.com/sigstore/cosign/v2/pkg/signature"
+
+	"github.com/falcosecurity/falcoctl/internal/cosign"
+)
+
+// requiredAnnotations converts an ArtifactPolicy's requiredAnnotations into
+// the shape cosign's CheckOpts.Annotations expects, so that verification
+// fails unless the signed payload carries every one of them.
+func requiredAnnotations(ap *ArtifactPolicy) sigs.AnnotationsMap {
+	if len(ap.RequiredAnnotations) == 0 {
+		return sigs.AnnotationsMap{}
+	}
+	annotations := make(map[string]interface{}, len(ap.RequiredAnnotations))
+	for k, v := range ap.RequiredAnnotations {
+		annotations[k] = v
+	}
+	return sigs.AnnotationsMap{Annotations: annotations}
+}
+
+// SignerAttempt records the outcome of evaluating a single signer block from
+// the policy against an artifact.
+type SignerAttempt struct {
+	// Description identifies the signer block that was tried, e.g.
+	// "keyless issuer=^https://token.actions.githubusercontent.com$".
+	Description string
+	Err         error
+}
+
+// Report is a structured account of why an artifact was, or was not, allowed
+// by the policy. It is returned regardless of the outcome so callers can
+// print a full explanation rather than just the first failure.
+type Report struct {
+	Image    string
+	Allowed  bool
+	Attempts []SignerAttempt
+}
+
+// Error renders the report as a human-readable explanation of a denial. It
+// is only meaningful when Allowed is false.
+func (r *Report) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "artifact %q was not allowed by policy:\n", r.Image)
+	for _, a := range r.Attempts {
+		fmt.Fprintf(&b, "  - %s: %v\n", a.Description, a.Err)
+	}
+	return b.String()
+}
+
+// Evaluate checks image against every signer block in ap, in order, stopping
+// at the first one that verifies successfully. It always returns a Report;
+// the returned error is non-nil (and equal to the report) only when no
+// signer block matched.
+func Evaluate(ctx context.Context, ap *ArtifactPolicy, image string) (*Report, error) {
+	report := &Report{Image: image}
+	annotations := requiredAnnotations(ap)
+
+	for _, signer := range ap.Keyless {
+		desc := fmt.Sprintf("keyless issuer=%q subject=%q", signer.IssuerRegexp, signer.SubjectRegexp)
+		vc := &cosign.VerifyCommand{
+			CertExtensions:       signer.CertExtensions,
+			BundlePath:           signer.BundlePath,
+			TrustedRootPath:      signer.TrustedRootPath,
+			TSACertChainPath:     signer.TSACertChainPath,
+			RFC3161TimestampPath: signer.RFC3161TimestampPath,
+			Annotations:          annotations,
+		}
+		vc.CertIdentityRegexp = signer.SubjectRegexp
+		vc.CertOidcIssuerRegexp = signer.IssuerRegexp
+
+		if err := vc.DoVerify(ctx, []string{image}); err != nil {
+			report.Attempts = append(report.Attempts, SignerAttempt{Description: desc, Err: err})
+			continue
+		}
+		report.Allowed = true
+		return report, nil
+	}
+
+	for _, signer := range ap.Key {
+		desc := fmt.Sprintf("key %s%s", signer.Path, signer.KMS)
+		vc := &cosign.VerifyCommand{
+			KeyRef:      signer.Path,
+			Annotations: annotations,
+		}
+		if signer.KMS != "" {
+			vc.KeyRef = signer.KMS
+		}
+
+		if err := vc.DoVerify(ctx, []string{image}); err != nil {
+			report.Attempts = append(report.Attempts, SignerAttempt{Description: desc, Err: err})
+			continue
+		}
+		report.Allowed = true
+		return report, nil
+	}
+
+	if len(report.Attempts) == 0 {
+		report.Attempts = append(report.Attempts, SignerAttempt{
+			Description: "policy",
+			Err:         fmt.Errorf("no keyless or key signer blocks configured"),
+		})
+	}
+
+	return report, report
+}
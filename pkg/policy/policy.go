@@ -0,0 +1,109 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policy implements falcoctl's declarative "which artifacts am I
+// willing to run" gate, evaluated by falcoctl itself against a user-authored
+// policy file before an OCI artifact is pulled and extracted. Evaluation is
+// not fully offline: verifying a signer block may contact the registry and
+// the public Sigstore services.
+package policy
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// KeySigner describes an acceptable non-keyless signer, identified by a
+// public key. Exactly one of Path or KMS should be set.
+type KeySigner struct {
+	Path string `json:"path,omitempty"`
+	KMS  string `json:"kms,omitempty"`
+}
+
+// KeylessSigner describes an acceptable keyless (Fulcio) signer, matched by
+// issuer/subject regular expressions and, optionally, required Fulcio
+// certificate extension values.
+type KeylessSigner struct {
+	IssuerRegexp   string            `json:"issuerRegexp,omitempty"`
+	SubjectRegexp  string            `json:"subjectRegexp,omitempty"`
+	CertExtensions map[string]string `json:"certExtensions,omitempty"`
+	// BundlePath, if set, verifies this signer against a Sigstore
+	// .sigstore/.sigstore.json bundle, taking the signature and Rekor entry
+	// from it instead of from the registry/transparency log. Unless
+	// TrustedRootPath is also set, the Fulcio roots and the Rekor and CT log
+	// public keys are still fetched online.
+	BundlePath string `json:"bundlePath,omitempty"`
+	// TrustedRootPath, if set, pins the Fulcio/CT log/Rekor/TSA trust
+	// material to a local trusted_root.json instead of the public
+	// Sigstore TUF root.
+	TrustedRootPath string `json:"trustedRootPath,omitempty"`
+	// TSACertChainPath, if set, requires and verifies an RFC3161 signed
+	// timestamp against this PEM certificate chain.
+	TSACertChainPath string `json:"tsaCertChainPath,omitempty"`
+	// RFC3161TimestampPath, if set, verifies a detached RFC3161 signed
+	// timestamp for the signature, rather than relying on the Rekor
+	// transparency log entry's integrated time. Requires TSACertChainPath.
+	RFC3161TimestampPath string `json:"rfc3161TimestampPath,omitempty"`
+}
+
+// ArtifactPolicy lists the signers acceptable for a given artifact type or
+// registry, plus any OCI annotations every accepted artifact must carry.
+type ArtifactPolicy struct {
+	Keyless             []KeylessSigner   `json:"keyless,omitempty"`
+	Key                 []KeySigner       `json:"key,omitempty"`
+	RequiredAnnotations map[string]string `json:"requiredAnnotations,omitempty"`
+}
+
+// Policy is the top-level schema for a `falcoctl artifact verify` policy
+// file. Registries maps a registry host to the policy that applies to
+// artifacts pulled from it, matched exactly (host/repository prefixes are
+// not matched); Default applies to any registry not present in that map.
+type Policy struct {
+	Default    *ArtifactPolicy            `json:"default,omitempty"`
+	Registries map[string]*ArtifactPolicy `json:"registries,omitempty"`
+}
+
+// Load reads and parses a policy file. Both YAML and JSON are accepted,
+// since JSON is a subset of YAML.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy file %q: %w", path, err)
+	}
+
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parsing policy file %q: %w", path, err)
+	}
+
+	if p.Default == nil && len(p.Registries) == 0 {
+		return nil, fmt.Errorf("policy file %q defines no default and no per-registry policy", path)
+	}
+
+	return &p, nil
+}
+
+// For returns the policy that applies to artifacts pulled from registry,
+// falling back to the default policy. It returns false if neither is set.
+func (p *Policy) For(registry string) (*ArtifactPolicy, bool) {
+	if ap, ok := p.Registries[registry]; ok {
+		return ap, true
+	}
+	if p.Default != nil {
+		return p.Default, true
+	}
+	return nil, false
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const samplePolicy = `
+default:
+  keyless:
+    - issuerRegexp: "^https://token.actions.githubusercontent.com$"
+      subjectRegexp: "^https://github.com/falcosecurity/.+$"
+registries:
+  ghcr.io:
+    keyless:
+      - issuerRegexp: "^https://token.actions.githubusercontent.com$"
+        subjectRegexp: "^https://github.com/falcosecurity/falcoctl/.+$"
+        certExtensions:
+          githubWorkflowRepository: falcosecurity/falcoctl
+`
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(samplePolicy), 0o600))
+
+	p, err := Load(path)
+	require.NoError(t, err)
+	require.NotNil(t, p.Default)
+	require.Len(t, p.Default.Keyless, 1)
+	require.Contains(t, p.Registries, "ghcr.io")
+}
+
+func TestPolicyFor(t *testing.T) {
+	p := &Policy{
+		Default:    &ArtifactPolicy{},
+		Registries: map[string]*ArtifactPolicy{"ghcr.io": {}},
+	}
+
+	ap, ok := p.For("ghcr.io")
+	require.True(t, ok)
+	require.Same(t, p.Registries["ghcr.io"], ap)
+
+	ap, ok = p.For("docker.io")
+	require.True(t, ok)
+	require.Same(t, p.Default, ap)
+}
+
+func TestRequiredAnnotations(t *testing.T) {
+	ap := &ArtifactPolicy{RequiredAnnotations: map[string]string{"org.opencontainers.image.source": "https://github.com/falcosecurity/falcoctl"}}
+	annotations := requiredAnnotations(ap)
+	require.Equal(t, "https://github.com/falcosecurity/falcoctl", annotations.Annotations["org.opencontainers.image.source"])
+
+	empty := requiredAnnotations(&ArtifactPolicy{})
+	require.Empty(t, empty.Annotations)
+}
+
+func TestLoadRejectsEmptyPolicy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "empty.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("{}"), 0o600))
+
+	_, err := Load(path)
+	require.Error(t, err)
+}